@@ -0,0 +1,183 @@
+package smtp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrAuthNotTLS is returned when an Auth mechanism that requires a secure
+// connection is attempted over a connection that is neither TLS nor to
+// localhost.
+var ErrAuthNotTLS = errors.New("smtp: server doesn't support AUTH over a non-TLS connection")
+
+// ErrAuthUnknownMechanism is returned when an Auth mechanism is started
+// that the server does not advertise support for.
+var ErrAuthUnknownMechanism = errors.New("smtp: server doesn't advertise requested AUTH mechanism")
+
+// ServerInfo describes the remote SMTP server as seen by an Auth
+// implementation.
+type ServerInfo struct {
+	// Name is the host the client connected to.
+	Name string
+	// TLS indicates whether the connection is using TLS.
+	TLS bool
+	// Auth lists the AUTH mechanisms advertised by the server in its
+	// EHLO response.
+	Auth []string
+}
+
+// Auth is implemented by an SMTP authentication mechanism.
+type Auth interface {
+	// Start begins an authentication with a server, returning the name
+	// of the authentication protocol and optionally data to include in
+	// the initial AUTH message sent to the server.
+	Start(server *ServerInfo) (proto string, toServer []byte, err error)
+	// Next continues the authentication. The server has just sent the
+	// fromServer data. If more is true, the server expects a response,
+	// which Next should return as toServer; otherwise Next should return
+	// toServer == nil.
+	Next(fromServer []byte, more bool) (toServer []byte, err error)
+}
+
+func serverSupports(server *ServerInfo, mech string) bool {
+	for _, m := range server.Auth {
+		if m == mech {
+			return true
+		}
+	}
+	return false
+}
+
+func requireTLSOrLocalhost(server *ServerInfo) error {
+	if server.TLS || server.Name == "localhost" {
+		return nil
+	}
+	return ErrAuthNotTLS
+}
+
+type plainAuth struct {
+	identity, username, password, host string
+}
+
+// PlainAuth returns an Auth that implements the PLAIN authentication
+// mechanism as defined in RFC 4616. The returned Auth uses host to ensure
+// the server's identity is not being spoofed and refuses to send
+// credentials over a non-TLS connection unless host is "localhost".
+func PlainAuth(identity, username, password, host string) Auth {
+	return &plainAuth{identity, username, password, host}
+}
+
+func (a *plainAuth) Start(server *ServerInfo) (string, []byte, error) {
+	if err := requireTLSOrLocalhost(server); err != nil {
+		return "", nil, err
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	if !serverSupports(server, "PLAIN") {
+		return "", nil, ErrAuthUnknownMechanism
+	}
+	resp := []byte(a.identity + "\x00" + a.username + "\x00" + a.password)
+	return "PLAIN", resp, nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("smtp: unexpected server challenge for PLAIN auth")
+	}
+	return nil, nil
+}
+
+type loginAuth struct {
+	username, password string
+}
+
+// LoginAuth returns an Auth that implements the LOGIN authentication
+// mechanism.
+func LoginAuth(username, password string) Auth {
+	return &loginAuth{username, password}
+}
+
+func (a *loginAuth) Start(server *ServerInfo) (string, []byte, error) {
+	if err := requireTLSOrLocalhost(server); err != nil {
+		return "", nil, err
+	}
+	if !serverSupports(server, "LOGIN") {
+		return "", nil, ErrAuthUnknownMechanism
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(a.username), nil
+	case "Password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("smtp: unexpected server challenge for LOGIN auth: %q", fromServer)
+	}
+}
+
+type cramMD5Auth struct {
+	username, secret string
+}
+
+// CRAMMD5Auth returns an Auth that implements the CRAM-MD5 authentication
+// mechanism as defined in RFC 2195. It does not require a TLS connection.
+func CRAMMD5Auth(username, secret string) Auth {
+	return &cramMD5Auth{username, secret}
+}
+
+func (a *cramMD5Auth) Start(server *ServerInfo) (string, []byte, error) {
+	if !serverSupports(server, "CRAM-MD5") {
+		return "", nil, ErrAuthUnknownMechanism
+	}
+	return "CRAM-MD5", nil, nil
+}
+
+func (a *cramMD5Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	d := hmac.New(md5.New, []byte(a.secret))
+	d.Write(fromServer)
+	sum := hex.EncodeToString(d.Sum(nil))
+	return []byte(a.username + " " + sum), nil
+}
+
+type xoauth2Auth struct {
+	username, token string
+}
+
+// XOAuth2 returns an Auth that implements the XOAUTH2 authentication
+// mechanism used by Gmail and other providers for OAuth2 access tokens.
+func XOAuth2(username, token string) Auth {
+	return &xoauth2Auth{username, token}
+}
+
+func (a *xoauth2Auth) Start(server *ServerInfo) (string, []byte, error) {
+	if err := requireTLSOrLocalhost(server); err != nil {
+		return "", nil, err
+	}
+	if !serverSupports(server, "XOAUTH2") {
+		return "", nil, ErrAuthUnknownMechanism
+	}
+	resp := []byte(fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01", a.username, a.token))
+	return "XOAUTH2", resp, nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server reports an error as a base64-decoded JSON payload
+		// and expects an empty response before failing the exchange.
+		return []byte{}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,90 @@
+package smtp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPlainAuth(t *testing.T) {
+	a := PlainAuth("ident", "user", "pass", "mail.example.com")
+
+	if _, _, err := a.Start(&ServerInfo{Name: "mail.example.com", TLS: false, Auth: []string{"PLAIN"}}); !errors.Is(err, ErrAuthNotTLS) {
+		t.Fatalf("Start over non-TLS, non-localhost: got err %v, want ErrAuthNotTLS", err)
+	}
+
+	proto, toServer, err := a.Start(&ServerInfo{Name: "mail.example.com", TLS: true, Auth: []string{"PLAIN"}})
+	if err != nil {
+		t.Fatalf("Start over TLS: %v", err)
+	}
+	if proto != "PLAIN" {
+		t.Errorf("proto = %q, want PLAIN", proto)
+	}
+	want := []byte("ident\x00user\x00pass")
+	if !bytes.Equal(toServer, want) {
+		t.Errorf("toServer = %q, want %q", toServer, want)
+	}
+
+	localAuth := PlainAuth("", "user", "pass", "localhost")
+	if _, _, err := localAuth.Start(&ServerInfo{Name: "localhost", TLS: false, Auth: []string{"PLAIN"}}); err != nil {
+		t.Errorf("Start over non-TLS localhost should be allowed, got %v", err)
+	}
+
+	if _, _, err := a.Start(&ServerInfo{Name: "mail.example.com", TLS: true, Auth: []string{"LOGIN"}}); !errors.Is(err, ErrAuthUnknownMechanism) {
+		t.Errorf("Start without matching advertised mechanism: got %v, want ErrAuthUnknownMechanism", err)
+	}
+}
+
+func TestLoginAuth(t *testing.T) {
+	a := LoginAuth("user", "pass")
+
+	toServer, err := a.Next([]byte("Username:"), true)
+	if err != nil || string(toServer) != "user" {
+		t.Errorf("Next(Username:) = (%q, %v), want (\"user\", nil)", toServer, err)
+	}
+	toServer, err = a.Next([]byte("Password:"), true)
+	if err != nil || string(toServer) != "pass" {
+		t.Errorf("Next(Password:) = (%q, %v), want (\"pass\", nil)", toServer, err)
+	}
+	if toServer, err := a.Next(nil, false); err != nil || toServer != nil {
+		t.Errorf("Next at end of exchange = (%q, %v), want (nil, nil)", toServer, err)
+	}
+}
+
+// TestCRAMMD5Auth uses the worked example from RFC 2195 §3.
+func TestCRAMMD5Auth(t *testing.T) {
+	a := CRAMMD5Auth("tim", "tanstaaftanstaaf")
+	challenge := []byte("<1896.697170952@postoffice.reston.mci.net>")
+	want := "tim b913a602c7eda7a495b4e6e7334d3890"
+
+	toServer, err := a.Next(challenge, true)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if string(toServer) != want {
+		t.Errorf("Next(challenge) = %q, want %q", toServer, want)
+	}
+}
+
+func TestXOAuth2(t *testing.T) {
+	a := XOAuth2("user@example.com", "sometoken")
+	_, toServer, err := a.Start(&ServerInfo{Name: "mail.example.com", TLS: true, Auth: []string{"XOAUTH2"}})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	want := []byte("user=user@example.com\x01auth=Bearer sometoken\x01\x01")
+	if !bytes.Equal(toServer, want) {
+		t.Errorf("toServer = %q, want %q", toServer, want)
+	}
+}
+
+func TestEncodeDecodeAuthData(t *testing.T) {
+	data := []byte("hello\x00world")
+	decoded, err := decodeAuthData(encodeAuthData(data))
+	if err != nil {
+		t.Fatalf("decodeAuthData: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("round-trip = %q, want %q", decoded, data)
+	}
+}
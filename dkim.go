@@ -0,0 +1,257 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DKIMSigner adds a DKIM-Signature header (RFC 6376) to a Request's
+// outbound message.
+type DKIMSigner struct {
+	// Domain is the "d=" tag: the domain the signature claims
+	// responsibility for.
+	Domain string
+	// Selector is the "s=" tag: the DNS selector under Domain that
+	// publishes the matching public key.
+	Selector string
+	// Signer produces the signature. It must be an *rsa.PrivateKey or an
+	// ed25519.PrivateKey.
+	Signer crypto.Signer
+	// Headers lists, in order, the header fields to sign. Fields not
+	// present in the message are skipped. Defaults to From, To, Subject,
+	// Date, and Message-Id.
+	Headers []string
+	// Canonicalization is "simple" or "relaxed", optionally given as
+	// "<header>/<body>" to use different algorithms for the header and
+	// body (e.g. "relaxed/simple"). Defaults to "relaxed/relaxed".
+	Canonicalization string
+}
+
+var defaultDKIMHeaders = []string{"From", "To", "Subject", "Date", "Message-Id"}
+
+func (s *DKIMSigner) canon() (headerCanon, bodyCanon string) {
+	c := s.Canonicalization
+	if c == "" {
+		c = "relaxed/relaxed"
+	}
+	parts := strings.SplitN(c, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], parts[0]
+	}
+	return parts[0], parts[1]
+}
+
+// sign returns the fully formed "DKIM-Signature: ...\r\n" header for
+// message, which must be a complete RFC 5322 message (headers, a blank
+// line, then the body) as produced by Request.Write.
+func (s *DKIMSigner) sign(message []byte) (string, error) {
+	alg, err := dkimAlgorithm(s.Signer)
+	if err != nil {
+		return "", err
+	}
+
+	headerBlock, body := splitMessage(message)
+	headers := parseHeaders(headerBlock)
+	headerCanon, bodyCanon := s.canon()
+
+	bh := sha256.Sum256(canonicalizeBody(body, bodyCanon))
+
+	names := s.Headers
+	if len(names) == 0 {
+		names = defaultDKIMHeaders
+	}
+	var signedData bytes.Buffer
+	var signedNames []string
+	for _, name := range names {
+		h := findHeader(headers, name)
+		if h == nil {
+			continue
+		}
+		signedData.WriteString(canonicalizeHeader(h.raw, headerCanon))
+		signedNames = append(signedNames, name)
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + alg,
+		"c=" + headerCanon + "/" + bodyCanon,
+		"d=" + s.Domain,
+		"s=" + s.Selector,
+		"t=" + strconv.FormatInt(time.Now().Unix(), 10),
+		"h=" + strings.Join(signedNames, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bh[:]),
+		"b=",
+	}
+	sigLine := "DKIM-Signature: " + strings.Join(tags, "; ")
+
+	// The DKIM-Signature header field is itself part of the signed data,
+	// canonicalized like any other signed header, but per RFC 6376
+	// 3.7 it must not end with CRLF since it is always last.
+	signedData.WriteString(strings.TrimSuffix(canonicalizeHeader(sigLine+"\r\n", headerCanon), "\r\n"))
+
+	sig, err := dkimSignData(s.Signer, signedData.Bytes())
+	if err != nil {
+		return "", err
+	}
+	b64Sig := base64.StdEncoding.EncodeToString(sig)
+
+	return strings.Replace(sigLine, "b=", "b="+b64Sig, 1) + "\r\n", nil
+}
+
+func dkimAlgorithm(signer crypto.Signer) (string, error) {
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return "ed25519-sha256", nil
+	case *rsa.PublicKey:
+		return "rsa-sha256", nil
+	default:
+		return "", errors.New("smtp: DKIMSigner: unsupported signer key type")
+	}
+}
+
+func dkimSignData(signer crypto.Signer, data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		// ed25519-sha256 signs the SHA-256 digest with pure Ed25519.
+		return signer.Sign(rand.Reader, h[:], crypto.Hash(0))
+	}
+	return signer.Sign(rand.Reader, h[:], crypto.SHA256)
+}
+
+// rawHeader is a single header field as it appeared in the message,
+// with any folded continuation lines rejoined.
+type rawHeader struct {
+	name string // lowercased
+	raw  string // original bytes, including the trailing CRLF
+}
+
+func splitMessage(message []byte) (headerBlock, body []byte) {
+	idx := bytes.Index(message, []byte("\r\n\r\n"))
+	if idx < 0 {
+		return message, nil
+	}
+	return message[:idx+2], message[idx+4:]
+}
+
+func parseHeaders(block []byte) []rawHeader {
+	var headers []rawHeader
+	for _, line := range strings.Split(string(block), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(headers) > 0 {
+			headers[len(headers)-1].raw += "\r\n" + line
+			continue
+		}
+		name := line
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			name = line[:idx]
+		}
+		headers = append(headers, rawHeader{name: strings.ToLower(name), raw: line})
+	}
+	for i := range headers {
+		headers[i].raw += "\r\n"
+	}
+	return headers
+}
+
+// findHeader returns the header field named name, or nil if the message
+// has none. When name occurs more than once, it returns the occurrence
+// closest to the body (the last one), per RFC 6376 §5.4.2: a name listed
+// once in h= signs the bottom-most instance of that header field.
+func findHeader(headers []rawHeader, name string) *rawHeader {
+	lower := strings.ToLower(name)
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].name == lower {
+			return &headers[i]
+		}
+	}
+	return nil
+}
+
+// canonicalizeHeader canonicalizes a single header field, raw, including
+// its trailing CRLF, per RFC 6376 3.4.1 ("simple") or 3.4.2 ("relaxed").
+func canonicalizeHeader(raw, mode string) string {
+	if mode == "simple" {
+		return raw
+	}
+	idx := strings.Index(raw, ":")
+	name, value := raw, ""
+	if idx >= 0 {
+		name, value = raw[:idx], raw[idx+1:]
+	}
+	value = collapseWSP(strings.ReplaceAll(value, "\r\n", ""))
+	return strings.ToLower(name) + ":" + strings.TrimSpace(value) + "\r\n"
+}
+
+// canonicalizeBody canonicalizes a message body per RFC 6376 3.4.3
+// ("simple") or 3.4.4 ("relaxed"): both strip any trailing empty lines
+// and ensure the remaining body, if non-empty, ends in a single CRLF;
+// "relaxed" additionally collapses intra-line WSP runs to a single
+// space and trims trailing WSP from every line.
+func canonicalizeBody(body []byte, mode string) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	if mode != "simple" {
+		for i, l := range lines {
+			lines[i] = strings.TrimRight(collapseWSP(l), " \t")
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		if mode == "simple" {
+			// RFC 6376 §3.4.3: "simple" canonicalization of an empty
+			// body is a single CRLF; only "relaxed" (§3.4.4) treats it
+			// as the empty string.
+			return []byte("\r\n")
+		}
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func collapseWSP(s string) string {
+	var b strings.Builder
+	prevWSP := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			if !prevWSP {
+				b.WriteByte(' ')
+			}
+			prevWSP = true
+			continue
+		}
+		prevWSP = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// writeSignedMessage writes r to w with a DKIM-Signature header
+// prepended, computed over the message r.Write produces.
+func writeSignedMessage(w io.Writer, r *Request) error {
+	var msg bytes.Buffer
+	if err := r.Write(&msg); err != nil {
+		return err
+	}
+	sig, err := r.DKIM.sign(msg.Bytes())
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, sig); err != nil {
+		return err
+	}
+	_, err = w.Write(msg.Bytes())
+	return err
+}
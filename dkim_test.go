@@ -0,0 +1,91 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizeHeaderSimple(t *testing.T) {
+	raw := "Subject:  Hello World  \r\n"
+	if got := canonicalizeHeader(raw, "simple"); got != raw {
+		t.Errorf("simple canonicalization changed the header: got %q, want %q", got, raw)
+	}
+}
+
+func TestCanonicalizeHeaderRelaxed(t *testing.T) {
+	raw := "Subject:  Hello \t World  \r\n"
+	want := "subject:Hello World\r\n"
+	if got := canonicalizeHeader(raw, "relaxed"); got != want {
+		t.Errorf("relaxed canonicalization = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalizeBody(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		mode string
+		want string
+	}{
+		{"simple strips trailing blank lines", "line one\r\nline two\r\n\r\n\r\n", "simple", "line one\r\nline two\r\n"},
+		{"simple adds missing trailing CRLF", "line one", "simple", "line one\r\n"},
+		{"relaxed collapses WSP", "line  one  \r\nline\ttwo\r\n", "relaxed", "line one\r\nline two\r\n"},
+		{"simple empty body is a single CRLF", "", "simple", "\r\n"},
+		{"relaxed empty body is empty", "", "relaxed", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := canonicalizeBody([]byte(c.body), c.mode)
+			if string(got) != c.want {
+				t.Errorf("canonicalizeBody(%q, %q) = %q, want %q", c.body, c.mode, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFindHeaderPicksLastOccurrence(t *testing.T) {
+	headers := parseHeaders([]byte("To: first@example.com\r\nTo: second@example.com\r\n"))
+	h := findHeader(headers, "To")
+	if h == nil {
+		t.Fatal("findHeader(To) = nil")
+	}
+	if !strings.Contains(h.raw, "second@example.com") {
+		t.Errorf("findHeader(To) = %q, want the last To: line", h.raw)
+	}
+}
+
+func TestWriteSignedMessageKeepsRepeatedToHeaders(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	r, err := NewRequest(context.Background(), []string{"first@example.com", "second@example.com"}, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	r.From = "from@example.com"
+	r.To = []string{"first@example.com", "second@example.com"}
+	r.Subject = "hi"
+	r.SetBody("text/plain", "hello")
+	r.DKIM = &DKIMSigner{Domain: "example.com", Selector: "sel1", Signer: priv}
+
+	var buf bytes.Buffer
+	if err := writeSignedMessage(&buf, r); err != nil {
+		t.Fatalf("writeSignedMessage: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "DKIM-Signature: ") {
+		t.Fatalf("message does not start with DKIM-Signature header:\n%s", out)
+	}
+	if !strings.Contains(out, "a=ed25519-sha256") {
+		t.Errorf("expected ed25519-sha256 algorithm tag, got:\n%s", out)
+	}
+	if strings.Count(out, "To: ") != 2 {
+		t.Fatalf("expected both To: lines to survive in the wire message:\n%s", out)
+	}
+}
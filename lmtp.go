@@ -0,0 +1,70 @@
+package smtp
+
+import (
+	"net/textproto"
+	"strings"
+)
+
+// protocol selects the application protocol a Client speaks.
+type protocol int
+
+const (
+	protoSMTP protocol = iota
+	protoLMTP
+)
+
+// NewLMTPClient returns a Client that delivers mail via LMTP (RFC 2033)
+// instead of SMTP: it issues LHLO rather than EHLO/HELO and, on Send,
+// reports per-recipient delivery status rather than a single response.
+//
+// addr is dialed as a Unix socket if it contains no ":", and as TCP
+// otherwise, matching how local MDAs are conventionally addressed (a
+// socket path or a "host:port" pair).
+func NewLMTPClient(addr string) *Client {
+	network := "tcp"
+	if !strings.Contains(addr, ":") {
+		network = "unix"
+	}
+	return &Client{
+		localHost:  "localhost",
+		remoteHost: addr,
+		proto:      protoLMTP,
+		network:    network,
+	}
+}
+
+// RecipientStatus is the delivery status an LMTP server reports for a
+// single recipient after DATA (RFC 2033 §4.2): one status line per RCPT
+// TO the server accepted, in the same order they were sent.
+type RecipientStatus struct {
+	Recipient string
+	Code      int
+	Message   string
+	// Err is non-nil when Code does not indicate success (2xx).
+	Err error
+}
+
+// readLMTPStatuses reads one delivery-status reply per recipient from
+// conn, matching them to recipients in order. It returns the first
+// per-recipient error alongside the full slice of statuses, so that
+// callers can inspect every recipient's outcome rather than just the
+// first failure.
+func readLMTPStatuses(conn *conn, recipients []string) ([]RecipientStatus, error) {
+	statuses := make([]RecipientStatus, len(recipients))
+	var firstErr error
+	for i, to := range recipients {
+		code, msg, err := conn.textConn.ReadResponse(0)
+		status := RecipientStatus{Recipient: to, Code: code, Message: msg}
+		switch {
+		case err != nil:
+			status.Err = err
+		case code/100 != 2:
+			status.Err = &textproto.Error{Code: code, Msg: msg}
+		}
+		if status.Err != nil && firstErr == nil {
+			firstErr = status.Err
+		}
+		statuses[i] = status
+	}
+	return statuses, firstErr
+}
@@ -0,0 +1,67 @@
+package smtp
+
+import (
+	"errors"
+	"net/textproto"
+	"testing"
+)
+
+func TestReadLMTPStatusesMixedPerRecipientResults(t *testing.T) {
+	recipients := []string{"to1@example.com", "to2@example.com", "to3@example.com"}
+
+	conn := newPipeConn(t, func(tp *textproto.Conn) {
+		tp.PrintfLine("250 2.1.5 %s delivered", recipients[0])
+		tp.PrintfLine("550 5.1.1 %s unknown user", recipients[1])
+		tp.PrintfLine("452 4.2.2 %s mailbox full", recipients[2])
+	})
+
+	statuses, err := readLMTPStatuses(conn, recipients)
+	if len(statuses) != len(recipients) {
+		t.Fatalf("len(statuses) = %d, want %d", len(statuses), len(recipients))
+	}
+
+	if statuses[0].Code != 250 || statuses[0].Err != nil {
+		t.Errorf("statuses[0] = %+v, want Code 250 and no error", statuses[0])
+	}
+
+	var terr *textproto.Error
+	if statuses[1].Code != 550 || !errors.As(statuses[1].Err, &terr) || terr.Code != 550 {
+		t.Errorf("statuses[1] = %+v, want Code 550 and a matching textproto.Error", statuses[1])
+	}
+
+	if statuses[2].Code != 452 || statuses[2].Err == nil {
+		t.Errorf("statuses[2] = %+v, want Code 452 and an error", statuses[2])
+	}
+
+	if err == nil {
+		t.Fatal("readLMTPStatuses: want the first per-recipient error, got nil")
+	}
+	if !errors.As(err, &terr) || terr.Code != 550 {
+		t.Errorf("err = %v, want the first failure (550, to2@example.com)", err)
+	}
+	for i, to := range recipients {
+		if statuses[i].Recipient != to {
+			t.Errorf("statuses[%d].Recipient = %q, want %q", i, statuses[i].Recipient, to)
+		}
+	}
+}
+
+func TestReadLMTPStatusesAllAccepted(t *testing.T) {
+	recipients := []string{"to1@example.com", "to2@example.com"}
+
+	conn := newPipeConn(t, func(tp *textproto.Conn) {
+		for _, to := range recipients {
+			tp.PrintfLine("250 2.1.5 %s delivered", to)
+		}
+	})
+
+	statuses, err := readLMTPStatuses(conn, recipients)
+	if err != nil {
+		t.Fatalf("readLMTPStatuses: %v", err)
+	}
+	for i, status := range statuses {
+		if status.Err != nil {
+			t.Errorf("statuses[%d].Err = %v, want nil", i, status.Err)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package smtp
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// bodyPart is a single text representation of the message body, such as
+// the plain-text or HTML alternative.
+type bodyPart struct {
+	contentType string
+	content     string
+}
+
+// attachmentPart is a file carried in a multipart/mixed or
+// multipart/related part. When cid is set, it is embedded inline and
+// referenced from the body by its Content-ID rather than offered as a
+// downloadable attachment.
+type attachmentPart struct {
+	filename    string
+	contentType string
+	cid         string
+	r           io.Reader
+}
+
+// AttachOption configures an attachment added with Request.Attach or
+// Request.Embed.
+type AttachOption func(*attachmentPart)
+
+// WithContentType overrides the Content-Type that would otherwise be
+// guessed from the attachment's filename extension.
+func WithContentType(contentType string) AttachOption {
+	return func(a *attachmentPart) {
+		a.contentType = contentType
+	}
+}
+
+// SetBody sets the primary representation of the message body. Calling
+// SetBody again replaces the previous body.
+func (r *Request) SetBody(contentType, body string) {
+	r.body = &bodyPart{contentType: contentType, content: body}
+}
+
+// AddAlternative adds an alternative representation of the message body,
+// such as an HTML part alongside a plain-text SetBody. Alternatives are
+// ordered from least to most preferred, matching the order they are
+// added in, which matches the convention of net/mail-producing clients.
+func (r *Request) AddAlternative(contentType, body string) {
+	r.alternatives = append(r.alternatives, &bodyPart{contentType: contentType, content: body})
+}
+
+// Attach adds filename as a downloadable attachment, read from rd at
+// Write time.
+func (r *Request) Attach(filename string, rd io.Reader, opts ...AttachOption) {
+	a := &attachmentPart{filename: filename, r: rd}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.contentType == "" {
+		a.contentType = contentTypeByFilename(filename)
+	}
+	r.attachments = append(r.attachments, a)
+}
+
+// Embed adds filename as an inline part referenced from the body via
+// "cid:<cid>", for example in an <img src="cid:...">  tag. The part is
+// written inside a multipart/related wrapper whether or not any body
+// part references its Content-ID.
+func (r *Request) Embed(cid, filename string, rd io.Reader) {
+	r.embeds = append(r.embeds, &attachmentPart{
+		filename:    filename,
+		contentType: contentTypeByFilename(filename),
+		cid:         cid,
+		r:           rd,
+	})
+}
+
+func contentTypeByFilename(filename string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(filename)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// mimeNode is a single part of the MIME structure being built for a
+// Request: either a leaf text part or a multipart part that wraps other
+// nodes.
+type mimeNode struct {
+	contentType      string
+	transferEncoding string
+	write            func(w io.Writer) error
+}
+
+// hasMIMEParts reports whether r was built via SetBody, AddAlternative,
+// Attach, or Embed, in which case Write emits a MIME structure instead of
+// copying Body verbatim.
+func (r *Request) hasMIMEParts() bool {
+	return r.body != nil || len(r.alternatives) > 0 || len(r.attachments) > 0 || len(r.embeds) > 0
+}
+
+// mimeNode builds the MIME tree for r: multipart/mixed wrapping
+// multipart/related wrapping multipart/alternative, omitting any level
+// that has nothing to wrap.
+func (r *Request) mimeNode() *mimeNode {
+	return r.mixedNode(r.relatedNode(r.alternativeNode()))
+}
+
+func (r *Request) alternativeNode() *mimeNode {
+	parts := make([]*bodyPart, 0, len(r.alternatives)+1)
+	if r.body != nil {
+		parts = append(parts, r.body)
+	}
+	parts = append(parts, r.alternatives...)
+
+	// Attach/Embed alone, with neither SetBody nor AddAlternative, still
+	// needs a text leaf to carry r.Body: fall back to copying it raw
+	// rather than fabricating an empty one and silently dropping it.
+	if len(parts) == 0 {
+		return &mimeNode{
+			contentType: "text/plain; charset=utf-8",
+			write: func(w io.Writer) error {
+				_, err := io.Copy(w, r.Body)
+				return err
+			},
+		}
+	}
+
+	if len(parts) == 1 {
+		p := *parts[0]
+		return &mimeNode{
+			contentType:      p.contentType + "; charset=utf-8",
+			transferEncoding: "quoted-printable",
+			write: func(w io.Writer) error {
+				return writeQuotedPrintable(w, p.content)
+			},
+		}
+	}
+
+	boundary := newBoundary()
+	return &mimeNode{
+		contentType: "multipart/alternative; boundary=" + boundary,
+		write: func(w io.Writer) error {
+			return writeTextParts(w, boundary, parts)
+		},
+	}
+}
+
+func (r *Request) relatedNode(inner *mimeNode) *mimeNode {
+	if len(r.embeds) == 0 {
+		return inner
+	}
+	boundary := newBoundary()
+	return &mimeNode{
+		contentType: "multipart/related; boundary=" + boundary,
+		write: func(w io.Writer) error {
+			return writeWrappedParts(w, boundary, inner, r.embeds)
+		},
+	}
+}
+
+func (r *Request) mixedNode(inner *mimeNode) *mimeNode {
+	if len(r.attachments) == 0 {
+		return inner
+	}
+	boundary := newBoundary()
+	return &mimeNode{
+		contentType: "multipart/mixed; boundary=" + boundary,
+		write: func(w io.Writer) error {
+			return writeWrappedParts(w, boundary, inner, r.attachments)
+		},
+	}
+}
+
+// newBoundary mints a multipart boundary the same way mime/multipart
+// does internally (crypto/rand), without needing a real target writer
+// yet: the Content-Type header naming the boundary must be written
+// before the part bodies that will use it.
+func newBoundary() string {
+	return multipart.NewWriter(io.Discard).Boundary()
+}
+
+func writeTextParts(w io.Writer, boundary string, parts []*bodyPart) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", p.contentType+"; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if err := writeQuotedPrintable(pw, p.content); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func writeWrappedParts(w io.Writer, boundary string, inner *mimeNode, parts []*attachmentPart) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", inner.contentType)
+	if inner.transferEncoding != "" {
+		h.Set("Content-Transfer-Encoding", inner.transferEncoding)
+	}
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	if err := inner.write(pw); err != nil {
+		return err
+	}
+
+	for _, a := range parts {
+		if err := writeAttachmentPart(mw, a); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func writeAttachmentPart(mw *multipart.Writer, a *attachmentPart) error {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", a.contentType)
+	h.Set("Content-Transfer-Encoding", "base64")
+	disposition := "attachment"
+	if a.cid != "" {
+		h.Set("Content-ID", "<"+stripCRLF(a.cid)+">")
+		disposition = "inline"
+	}
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": stripCRLF(a.filename)}))
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	return writeBase64(pw, a.r)
+}
+
+// stripCRLF removes CR and LF bytes from s, which is bound for a header
+// value elsewhere in this file: mime/multipart.Writer.CreatePart writes
+// header values verbatim, so a filename or Content-ID containing a raw
+// CRLF would let a caller-supplied string inject arbitrary header lines
+// into the generated MIME part.
+func stripCRLF(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, content); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+const base64LineLen = 76
+
+// lineBreaker inserts a CRLF every base64LineLen bytes written to it, so
+// that base64-encoded parts wrap the same way real MTAs emit them.
+type lineBreaker struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineBreaker) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := base64LineLen - l.col
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+		if l.col == base64LineLen {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
+
+func (l *lineBreaker) Close() error {
+	if l.col == 0 {
+		return nil
+	}
+	_, err := l.w.Write([]byte("\r\n"))
+	return err
+}
+
+func writeBase64(w io.Writer, r io.Reader) error {
+	lb := &lineBreaker{w: w}
+	enc := base64.NewEncoder(base64.StdEncoding, lb)
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return lb.Close()
+}
+
+// encodeHeaderValue RFC 2047-encodes s as a base64 "encoded-word" if it
+// contains any non-ASCII byte; ASCII values are returned unchanged.
+func encodeHeaderValue(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	return mime.BEncoding.Encode("UTF-8", s)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > 127 {
+			return false
+		}
+	}
+	return true
+}
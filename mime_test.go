@@ -0,0 +1,223 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+)
+
+func newTestRequest(t *testing.T) *Request {
+	t.Helper()
+	r, err := NewRequest(context.Background(), []string{"to@example.com"}, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	r.From = "from@example.com"
+	r.Subject = "hello"
+	return r
+}
+
+// parseMultipart parses a message written by Request.Write, returning its
+// top-level Content-Type and, when that is multipart, the media type of
+// each leaf part in order, descending into nested multipart parts.
+func parseMultipart(t *testing.T, raw []byte) (topContentType string, leaves []string) {
+	t.Helper()
+	idx := bytes.Index(raw, []byte("\r\n\r\n"))
+	if idx < 0 {
+		t.Fatalf("no header/body separator in message:\n%s", raw)
+	}
+	headerBlock, body := raw[:idx], raw[idx+4:]
+
+	var contentType string
+	for _, line := range strings.Split(string(headerBlock), "\r\n") {
+		if strings.HasPrefix(strings.ToLower(line), "content-type:") {
+			contentType = strings.TrimSpace(line[len("content-type:"):])
+		}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("ParseMediaType(%q): %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return contentType, nil
+	}
+
+	var walk func(r io.Reader, boundary string)
+	walk = func(r io.Reader, boundary string) {
+		mr := multipart.NewReader(r, boundary)
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextPart: %v", err)
+			}
+			pct := p.Header.Get("Content-Type")
+			pMediaType, pParams, err := mime.ParseMediaType(pct)
+			if err != nil {
+				t.Fatalf("ParseMediaType(%q): %v", pct, err)
+			}
+			if strings.HasPrefix(pMediaType, "multipart/") {
+				walk(p, pParams["boundary"])
+				continue
+			}
+			leaves = append(leaves, pMediaType)
+		}
+	}
+	walk(bytes.NewReader(body), params["boundary"])
+	return contentType, leaves
+}
+
+func TestRequestWriteSetBodyOnly(t *testing.T) {
+	r := newTestRequest(t)
+	r.SetBody("text/plain", "plain body")
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	ct, leaves := parseMultipart(t, buf.Bytes())
+	if !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain prefix", ct)
+	}
+	if len(leaves) != 0 {
+		t.Errorf("expected a single leaf part, not multipart; got leaves %v", leaves)
+	}
+}
+
+func TestRequestWriteAlternatives(t *testing.T) {
+	r := newTestRequest(t)
+	r.SetBody("text/plain", "plain body")
+	r.AddAlternative("text/html", "<b>html body</b>")
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	ct, leaves := parseMultipart(t, buf.Bytes())
+	if !strings.HasPrefix(ct, "multipart/alternative") {
+		t.Errorf("Content-Type = %q, want multipart/alternative prefix", ct)
+	}
+	want := []string{"text/plain", "text/html"}
+	if !equalStrings(leaves, want) {
+		t.Errorf("leaves = %v, want %v", leaves, want)
+	}
+}
+
+func TestRequestWriteMixedWithAttachment(t *testing.T) {
+	r := newTestRequest(t)
+	r.SetBody("text/plain", "plain body")
+	r.Attach("report.csv", strings.NewReader("a,b,c"), WithContentType("text/csv"))
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	ct, leaves := parseMultipart(t, buf.Bytes())
+	if !strings.HasPrefix(ct, "multipart/mixed") {
+		t.Errorf("Content-Type = %q, want multipart/mixed prefix", ct)
+	}
+	want := []string{"text/plain", "text/csv"}
+	if !equalStrings(leaves, want) {
+		t.Errorf("leaves = %v, want %v", leaves, want)
+	}
+}
+
+func TestAttachFilenameCRLFInjection(t *testing.T) {
+	r := newTestRequest(t)
+	r.SetBody("text/plain", "plain body")
+	r.Attach("a.txt\"\r\nBcc: attacker@evil.com\r\nX-Injected: yes", strings.NewReader("data"))
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if line == "Bcc: attacker@evil.com" || line == "X-Injected: yes" {
+			t.Fatalf("CRLF in filename injected a standalone header line %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestEmbedCIDCRLFInjection(t *testing.T) {
+	r := newTestRequest(t)
+	r.SetBody("text/html", "<b>hi</b>")
+	r.Embed("logo\r\nBcc: attacker@evil.com", "logo.png", strings.NewReader("data"))
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if line == "Bcc: attacker@evil.com" {
+			t.Fatalf("CRLF in cid injected a standalone header line %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestRequestWriteAttachmentWithoutSetBodyKeepsBody(t *testing.T) {
+	r, err := NewRequest(context.Background(), []string{"to@example.com"}, strings.NewReader("IMPORTANT BODY TEXT"))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	r.From = "from@example.com"
+	r.Attach("report.csv", strings.NewReader("a,b,c"), WithContentType("text/csv"))
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(buf.String(), "IMPORTANT BODY TEXT") {
+		t.Errorf("Attach without SetBody dropped r.Body; message:\n%s", buf.String())
+	}
+}
+
+func TestRequestWriteLegacyBodyUnchanged(t *testing.T) {
+	r, err := NewRequest(context.Background(), []string{"to@example.com"}, strings.NewReader("legacy body"))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	r.From = "from@example.com"
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if strings.Contains(buf.String(), "MIME-Version") {
+		t.Errorf("legacy Request.Body path should not emit MIME headers:\n%s", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), "legacy body") {
+		t.Errorf("body not copied verbatim:\n%s", buf.String())
+	}
+}
+
+func TestWriteBase64LineBreaker(t *testing.T) {
+	var buf bytes.Buffer
+	data := bytes.Repeat([]byte("x"), 100)
+	if err := writeBase64(&buf, bytes.NewReader(data)); err != nil {
+		t.Fatalf("writeBase64: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > base64LineLen {
+			t.Errorf("line longer than %d columns: %q", base64LineLen, line)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,335 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxMessagesPerConn is the default number of messages a Pool will
+// send on a single connection before reconnecting.
+const DefaultMaxMessagesPerConn = 100
+
+// DefaultIdleTimeout is the default duration a Pool will keep an unused
+// connection open before reconnecting on the next Send or SendBatch.
+const DefaultIdleTimeout = 30 * time.Second
+
+// Pool sends many Requests over a single, reused connection to an SMTP
+// server, issuing RSET between envelopes instead of paying for a fresh
+// TCP+TLS handshake and EHLO per message. A Pool is safe for concurrent
+// use; Send and SendBatch calls are serialized on the pooled connection.
+//
+// Pool only supports plain SMTP clients: an LMTP Client (see
+// NewLMTPClient) replies to DATA with one status line per recipient
+// rather than a single 250, which Pool does not parse, so a Pool backed
+// by an LMTP Client returns an error from Send and SendBatch without
+// dialing.
+type Pool struct {
+	client      *Client
+	maxMessages int
+	idleTimeout time.Duration
+	tlsConfig   *tls.Config
+	auth        Auth
+
+	mu     sync.Mutex
+	conn   *conn
+	sent   int
+	active time.Time
+}
+
+// PoolOption configures a Pool returned by NewPool.
+type PoolOption func(*Pool)
+
+// WithMaxMessagesPerConn overrides DefaultMaxMessagesPerConn.
+func WithMaxMessagesPerConn(n int) PoolOption {
+	return func(p *Pool) {
+		p.maxMessages = n
+	}
+}
+
+// WithIdleTimeout overrides DefaultIdleTimeout.
+func WithIdleTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.idleTimeout = d
+	}
+}
+
+// WithPoolStartTLS enables STARTTLS on the pooled connection using config,
+// performed once when the connection is established rather than per
+// message.
+func WithPoolStartTLS(config *tls.Config) PoolOption {
+	return func(p *Pool) {
+		p.tlsConfig = config
+	}
+}
+
+// WithPoolAuth authenticates the pooled connection with a once it is
+// established, rather than per message.
+func WithPoolAuth(a Auth) PoolOption {
+	return func(p *Pool) {
+		p.auth = a
+	}
+}
+
+// NewPool returns a new Pool backed by c.
+func NewPool(c *Client, opts ...PoolOption) *Pool {
+	p := &Pool{
+		client:      c,
+		maxMessages: DefaultMaxMessagesPerConn,
+		idleTimeout: DefaultIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Close closes the pooled connection, if any. It is safe to keep using
+// the Pool afterward; the next Send or SendBatch reconnects.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeConnLocked()
+}
+
+func (p *Pool) closeConnLocked() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.close()
+	p.conn = nil
+	return err
+}
+
+// getConnLocked returns a connection ready to accept a MAIL FROM,
+// reusing the pooled one if it is still within its message and idle
+// budget, or dialing and handshaking a new one otherwise.
+func (p *Pool) getConnLocked() (*conn, error) {
+	if p.client.proto == protoLMTP {
+		return nil, errors.New("smtp: Pool does not support LMTP clients")
+	}
+	if p.conn != nil {
+		if p.sent < p.maxMessages && time.Since(p.active) < p.idleTimeout {
+			return p.conn, nil
+		}
+		p.closeConnLocked()
+	}
+
+	c, err := p.client.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.client.hello(c, p.client.localHost); err != nil {
+		c.close()
+		return nil, err
+	}
+	if _, ok := p.client.ext["STARTTLS"]; ok && p.tlsConfig != nil {
+		if err := p.client.startTLS(c, p.tlsConfig); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	if p.auth != nil {
+		if _, ok := p.client.ext["AUTH"]; !ok {
+			c.close()
+			return nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := p.client.authenticate(c, p.auth); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	p.conn = c
+	p.sent = 0
+	return c, nil
+}
+
+// Send sends r on the pooled connection, reconnecting first if needed.
+func (p *Pool) Send(r *Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, err := p.getConnLocked()
+	if err != nil {
+		return err
+	}
+	if p.sent > 0 {
+		if _, _, err := c.execCmd(250, "RSET"); err != nil {
+			p.closeConnLocked()
+			return err
+		}
+	}
+	if err := p.sendOn(c, r); err != nil {
+		p.closeConnLocked()
+		return err
+	}
+	p.sent++
+	p.active = time.Now()
+	return nil
+}
+
+// sendOn issues MAIL/RCPT/DATA for r on c, pipelining them into a single
+// write when the server advertises PIPELINING.
+func (p *Pool) sendOn(c *conn, r *Request) error {
+	if _, ok := p.client.ext["PIPELINING"]; ok {
+		return p.client.sendEnvelopePipelined(c, r)
+	}
+	return p.client.sendEnvelopeSequential(c, r)
+}
+
+// SendBatch sends every request in rs on the pooled connection, one
+// envelope at a time, stopping early if ctx is canceled. It always
+// returns a slice the same length as rs; a per-request error describes
+// RCPT failures for that request without aborting delivery to the
+// recipients that were accepted.
+func (p *Pool) SendBatch(ctx context.Context, rs []*Request) []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := make([]error, len(rs))
+	for i, r := range rs {
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(rs); j++ {
+				errs[j] = err
+			}
+			break
+		}
+
+		c, err := p.getConnLocked()
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if p.sent > 0 {
+			if _, _, err := c.execCmd(250, "RSET"); err != nil {
+				p.closeConnLocked()
+				errs[i] = err
+				continue
+			}
+		}
+		if err := p.sendOn(c, r); err != nil {
+			errs[i] = err
+			p.closeConnLocked()
+			continue
+		}
+		p.sent++
+		p.active = time.Now()
+	}
+	return errs
+}
+
+// sendEnvelopeSequential issues MAIL/RCPT/DATA for r on conn one command
+// at a time, the way a single Client.Send does, but continues to DATA as
+// long as at least one recipient was accepted instead of aborting on the
+// first RCPT failure.
+func (c *Client) sendEnvelopeSequential(conn *conn, r *Request) error {
+	if err := c.mail(conn, r.From); err != nil {
+		return err
+	}
+	accepted, rcptErrs := c.rcptAll(conn, r)
+	if accepted == 0 {
+		return errors.Join(rcptErrs...)
+	}
+	if _, _, err := conn.execCmd(354, "DATA"); err != nil {
+		return errors.Join(append(rcptErrs, err)...)
+	}
+	return errors.Join(append(rcptErrs, writeData(conn, r))...)
+}
+
+// sendEnvelopePipelined coalesces MAIL FROM, every RCPT TO, and DATA into
+// a single write, then reads the responses back in order, as allowed
+// when the server has advertised PIPELINING.
+func (c *Client) sendEnvelopePipelined(conn *conn, r *Request) error {
+	mailCmd := "MAIL FROM:<%s>"
+	if _, ok := c.ext["8BITMIME"]; ok {
+		mailCmd += " BODY=8BITMIME"
+	}
+	mailID, err := conn.textConn.Cmd(mailCmd, r.From)
+	if err != nil {
+		return err
+	}
+
+	recipients := append(append([]string{}, r.To...), r.Bcc...)
+	rcptIDs := make([]uint, len(recipients))
+	for i, addr := range recipients {
+		id, err := conn.textConn.Cmd("RCPT TO:<%s>", addr)
+		if err != nil {
+			return err
+		}
+		rcptIDs[i] = id
+	}
+
+	dataID, err := conn.textConn.Cmd("DATA")
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := conn.readResponse(mailID, 250); err != nil {
+		for _, id := range rcptIDs {
+			conn.readResponse(id, 0)
+		}
+		conn.readResponse(dataID, 0)
+		return err
+	}
+
+	var rcptErrs []error
+	accepted := 0
+	for i, id := range rcptIDs {
+		if _, _, err := conn.readResponse(id, 25); err != nil {
+			rcptErrs = append(rcptErrs, fmt.Errorf("smtp: rcpt to %s: %w", recipients[i], err))
+			continue
+		}
+		accepted++
+	}
+
+	if accepted == 0 {
+		conn.readResponse(dataID, 0)
+		return errors.Join(rcptErrs...)
+	}
+	if _, _, err := conn.readResponse(dataID, 354); err != nil {
+		return errors.Join(append(rcptErrs, err)...)
+	}
+	return errors.Join(append(rcptErrs, writeData(conn, r))...)
+}
+
+// rcptAll issues RCPT TO for every To and Bcc address in r, matching the
+// set of recipients Client.Send rcpts to, and reports how many were
+// accepted alongside an error per rejection.
+func (c *Client) rcptAll(conn *conn, r *Request) (accepted int, rcptErrs []error) {
+	for _, addr := range append(append([]string{}, r.To...), r.Bcc...) {
+		if _, _, err := conn.execCmd(25, "RCPT TO:<%s>", addr); err != nil {
+			rcptErrs = append(rcptErrs, fmt.Errorf("smtp: rcpt to %s: %w", addr, err))
+			continue
+		}
+		accepted++
+	}
+	return accepted, rcptErrs
+}
+
+func writeData(conn *conn, r *Request) error {
+	w := conn.textConn.DotWriter()
+	var err error
+	if r.DKIM != nil {
+		err = writeSignedMessage(w, r)
+	} else {
+		err = r.Write(w)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	_, _, err = conn.textConn.ReadResponse(250)
+	return err
+}
+
+func (c *conn) readResponse(id uint, expectCode int) (int, string, error) {
+	c.textConn.StartResponse(id)
+	defer c.textConn.EndResponse(id)
+	return c.textConn.ReadResponse(expectCode)
+}
@@ -0,0 +1,143 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// newPipeConn returns a *conn backed by a loopback TCP socket, with server
+// serving the other end. A real socket, rather than net.Pipe, is used
+// because it has kernel write buffering: pipelined commands are written
+// to the wire before their responses are read back, which would
+// deadlock on net.Pipe's unbuffered, fully synchronous rendezvous.
+func newPipeConn(t *testing.T, server func(tp *textproto.Conn)) *conn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		serverSide, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer serverSide.Close()
+		server(textproto.NewConn(serverSide))
+	}()
+
+	clientSide, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { clientSide.Close() })
+	return &conn{textConn: textproto.NewConn(clientSide), netConn: clientSide}
+}
+
+func poolTestRequest(t *testing.T, to ...string) *Request {
+	t.Helper()
+	r, err := NewRequest(context.Background(), to, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	r.From = "from@example.com"
+	r.To = to
+	r.Subject = "hi"
+	r.SetBody("text/plain", "hello")
+	return r
+}
+
+func TestSendEnvelopePipelinedPartialRCPTFailure(t *testing.T) {
+	c := &Client{ext: map[string]string{"PIPELINING": ""}}
+	r := poolTestRequest(t, "good@example.com", "bad@example.com")
+
+	conn := newPipeConn(t, func(tp *textproto.Conn) {
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read MAIL: %v", err)
+		}
+		tp.PrintfLine("250 OK")
+
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read RCPT good: %v", err)
+		}
+		tp.PrintfLine("250 OK")
+
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read RCPT bad: %v", err)
+		}
+		tp.PrintfLine("550 no such user")
+
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read DATA: %v", err)
+		}
+		tp.PrintfLine("354 go ahead")
+
+		if _, err := io.ReadAll(tp.DotReader()); err != nil {
+			t.Errorf("read message body: %v", err)
+		}
+		tp.PrintfLine("250 accepted")
+	})
+
+	err := c.sendEnvelopePipelined(conn, r)
+	if err == nil {
+		t.Fatal("sendEnvelopePipelined: want an error describing the rejected recipient, got nil")
+	}
+	if !strings.Contains(err.Error(), "bad@example.com") {
+		t.Errorf("error = %q, want it to mention the rejected recipient", err)
+	}
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Code != 550 {
+		t.Errorf("error = %v, want a wrapped 550 textproto.Error", err)
+	}
+}
+
+func TestSendEnvelopePipelinedMailFailsDrainsQueuedResponses(t *testing.T) {
+	c := &Client{ext: map[string]string{"PIPELINING": ""}}
+	r := poolTestRequest(t, "to1@example.com", "to2@example.com")
+
+	conn := newPipeConn(t, func(tp *textproto.Conn) {
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read MAIL: %v", err)
+		}
+		tp.PrintfLine("451 mail from rejected")
+
+		for i := 0; i < len(r.To); i++ {
+			if _, err := tp.ReadLine(); err != nil {
+				t.Errorf("read RCPT %d: %v", i, err)
+			}
+			tp.PrintfLine("503 bad sequence of commands")
+		}
+
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read DATA: %v", err)
+		}
+		tp.PrintfLine("503 bad sequence of commands")
+
+		if _, err := tp.ReadLine(); err != nil {
+			t.Errorf("read NOOP: %v", err)
+		}
+		tp.PrintfLine("250 NOOP OK")
+	})
+
+	err := c.sendEnvelopePipelined(conn, r)
+	if err == nil {
+		t.Fatal("sendEnvelopePipelined: want an error when MAIL is rejected, got nil")
+	}
+	var terr *textproto.Error
+	if !errors.As(err, &terr) || terr.Code != 451 {
+		t.Errorf("error = %v, want the wrapped 451 MAIL failure", err)
+	}
+
+	// The connection must stay in sync after the drain: the next exchange
+	// reads the response the fake server queued up above, not a leftover
+	// RCPT/DATA response.
+	if _, _, err := conn.execCmd(250, "NOOP"); err != nil {
+		t.Errorf("connection desynced after drain: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package smtp
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -22,6 +23,8 @@ type Client struct {
 	localHost  string
 	ext        map[string]string
 	auth       []string
+	proto      protocol
+	network    string
 }
 
 // NewClient returns new Client.
@@ -35,6 +38,7 @@ func NewClient(host string) *Client {
 type conn struct {
 	textConn *textproto.Conn
 	netConn  net.Conn
+	isTLS    bool
 }
 
 func (c *conn) close() error {
@@ -61,12 +65,94 @@ func validateLine(line string) error {
 	return nil
 }
 
+// perHostDialTimeout bounds how long dial waits to connect to a single
+// candidate host before moving on to the next one.
+const perHostDialTimeout = 30 * time.Second
+
+// initialMXRetryDelay and maxMXRetryDelay bound the exponential backoff
+// dial applies between candidate hosts after a connection attempt fails.
+const (
+	initialMXRetryDelay = 100 * time.Millisecond
+	maxMXRetryDelay     = 5 * time.Second
+)
+
 func (c *Client) dial() (*conn, error) {
-	var netConn net.Conn
+	return c.DialContext(context.Background())
+}
+
+// DialContext dials the remote server, allowing ctx to cancel any
+// in-progress or still-pending connection attempt and the backoff wait
+// between hosts. The MX lookup itself does not observe ctx.
+//
+// For an LMTP Client (see NewLMTPClient), it connects directly to
+// remoteHost, over a Unix socket or TCP as appropriate. Otherwise it
+// looks up remoteHost's MX records and tries each host in preference
+// order, falling back to remoteHost's own A/AAAA records when it has no
+// MX per RFC 5321 §5.1, applying a per-host timeout and an exponential
+// backoff between attempts. If every host fails, the returned error
+// describes each attempt.
+func (c *Client) DialContext(ctx context.Context) (*conn, error) {
+	if c.network == "unix" {
+		return c.dialAddr(ctx, "unix", c.remoteHost)
+	}
+	if c.proto == protoLMTP {
+		return c.dialAddr(ctx, "tcp", c.remoteHost)
+	}
+
 	host, port, err := net.SplitHostPort(c.remoteHost)
 	if err != nil {
 		return nil, err
 	}
+	hosts, err := lookupMXHosts(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	delay := initialMXRetryDelay
+	for i, h := range hosts {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				errs = append(errs, ctx.Err())
+				return nil, fmt.Errorf("smtp: dial %s: %w", c.remoteHost, errors.Join(errs...))
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > maxMXRetryDelay {
+				delay = maxMXRetryDelay
+			}
+		}
+		hostCtx, cancel := context.WithTimeout(ctx, perHostDialTimeout)
+		conn, err := c.dialAddr(hostCtx, "tcp", net.JoinHostPort(h, port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", h, err))
+	}
+	return nil, fmt.Errorf("smtp: dial %s: %w", c.remoteHost, errors.Join(errs...))
+}
+
+func (c *Client) dialAddr(ctx context.Context, network, addr string) (*conn, error) {
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	textConn := textproto.NewConn(netConn)
+	if _, _, err := textConn.ReadCodeLine(220); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &conn{
+		textConn: textConn,
+		netConn:  netConn,
+	}, nil
+}
+
+// lookupMXHosts returns the MX hosts for host in preference order, or
+// host itself if it has no MX records, per RFC 5321 §5.1.
+func lookupMXHosts(host string) ([]string, error) {
 	var mxRecords []*net.MX
 	var tempDelay time.Duration
 	for {
@@ -95,36 +181,33 @@ func (c *Client) dial() (*conn, error) {
 			return nil, err
 		}
 		if de.IsNotFound {
-			mxRecords = []*net.MX{{Host: host}}
-			break
-		}
-	}
-	for _, mx := range mxRecords {
-		netConn, err = net.Dial("tcp", mx.Host+":"+port)
-		if err == nil {
-			break
+			return []string{host}, nil
 		}
-	}
-	if err != nil {
 		return nil, err
 	}
-
-	textConn := textproto.NewConn(netConn)
-	if _, _, err := textConn.ReadCodeLine(220); err != nil {
-		return nil, err
+	if len(mxRecords) == 0 {
+		return []string{host}, nil
 	}
-	return &conn{
-		textConn: textConn,
-		netConn:  netConn,
-	}, nil
+	hosts := make([]string, len(mxRecords))
+	for i, mx := range mxRecords {
+		hosts[i] = strings.TrimSuffix(mx.Host, ".")
+	}
+	return hosts, nil
 }
 
 func (c *Client) hello(conn *conn, localHost string) error {
 	if err := validateLine(localHost); err != nil {
 		return err
 	}
-	_, msg, err := conn.execCmd(250, "EHLO %s", localHost)
+	helloCmd := "EHLO"
+	if c.proto == protoLMTP {
+		helloCmd = "LHLO"
+	}
+	_, msg, err := conn.execCmd(250, helloCmd+" %s", localHost)
 	if err != nil {
+		if c.proto == protoLMTP {
+			return err
+		}
 		if _, _, err := conn.execCmd(250, "HELO %s", localHost); err != nil {
 			return err
 		}
@@ -139,6 +222,14 @@ func (c *Client) hello(conn *conn, localHost string) error {
 	return nil
 }
 
+func encodeAuthData(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func decodeAuthData(msg string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(msg)
+}
+
 func parseExt(ehloMsg string) map[string]string {
 	extMsgs := strings.Split(ehloMsg, "\n")
 	ext := make(map[string]string, len(extMsgs)-1)
@@ -173,19 +264,83 @@ func (c *Client) startTLS(conn *conn, config *tls.Config) error {
 	}
 	conn.netConn = tls.Client(conn.netConn, config)
 	conn.textConn = textproto.NewConn(conn.netConn)
+	conn.isTLS = true
 	return c.hello(conn, c.localHost)
 }
 
-// Send sends an email with the request r.
-func (c *Client) Send(r *Request) error {
-	conn, err := c.dial()
+// serverName returns the bare hostname of the server a Client talks to,
+// stripping the ":port" suffix net.SplitHostPort expects on remoteHost.
+// remoteHost has no such suffix for an LMTP Client dialing a Unix
+// socket, in which case it is returned unchanged.
+func (c *Client) serverName() string {
+	host, _, err := net.SplitHostPort(c.remoteHost)
+	if err != nil {
+		return c.remoteHost
+	}
+	return host
+}
+
+// authenticate runs the authentication exchange described by a against
+// conn, issuing AUTH and looping on 334 challenges until the server
+// returns a final 235 success or 535 failure.
+func (c *Client) authenticate(conn *conn, a Auth) error {
+	server := &ServerInfo{
+		Name: c.serverName(),
+		TLS:  conn.isTLS,
+		Auth: c.auth,
+	}
+	proto, toServer, err := a.Start(server)
 	if err != nil {
 		return err
 	}
+	cmdStr := "AUTH " + proto
+	if toServer != nil {
+		cmdStr += " " + encodeAuthData(toServer)
+	}
+	code, msg, err := conn.execCmd(0, "%s", cmdStr)
+	for err == nil {
+		var more bool
+		switch code {
+		case 334:
+			more = true
+		case 235:
+			more = false
+		default:
+			return &textproto.Error{Code: code, Msg: msg}
+		}
+		var fromServer []byte
+		if more {
+			fromServer, err = decodeAuthData(msg)
+			if err != nil {
+				return err
+			}
+		}
+		toServer, err = a.Next(fromServer, more)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+		code, msg, err = conn.execCmd(0, "%s", encodeAuthData(toServer))
+	}
+	return err
+}
+
+// Send sends an email with the request r. For a plain SMTP Client, the
+// returned []RecipientStatus is always nil; for an LMTP Client (see
+// NewLMTPClient), it holds the per-recipient delivery status the server
+// returns after DATA, one entry per recipient in the order they were
+// given to RCPT TO.
+func (c *Client) Send(r *Request) ([]RecipientStatus, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
 	defer conn.close()
 
 	if err := c.hello(conn, c.localHost); err != nil {
-		return err
+		return nil, err
 	}
 
 	if _, ok := c.ext["STARTTLS"]; ok && r.StartTLS {
@@ -194,44 +349,60 @@ func (c *Client) Send(r *Request) error {
 			tlsCfg = &tls.Config{ServerName: c.remoteHost}
 		}
 		if err := c.startTLS(conn, tlsCfg); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	if r.Auth != nil {
+		if _, ok := c.ext["AUTH"]; !ok {
+			return nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.authenticate(conn, r.Auth); err != nil {
+			return nil, err
 		}
 	}
 
 	if err := c.mail(conn, r.From); err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, to := range r.To {
+	recipients := append(append([]string{}, r.To...), r.Bcc...)
+	for _, to := range recipients {
 		if _, _, err := conn.execCmd(25, "RCPT TO:<%s>", to); err != nil {
-			return err
-		}
-	}
-	for _, bcc := range r.Bcc {
-		if _, _, err := conn.execCmd(25, "RCPT TO:<%s>", bcc); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if _, _, err := conn.execCmd(354, "DATA"); err != nil {
-		return err
+		return nil, err
 	}
 
 	w := conn.textConn.DotWriter()
-	if err := r.Write(w); err != nil {
-		return err
+	if r.DKIM != nil {
+		if err := writeSignedMessage(w, r); err != nil {
+			return nil, err
+		}
+	} else if err := r.Write(w); err != nil {
+		return nil, err
 	}
 	if err := w.Close(); err != nil {
-		return err
+		return nil, err
 	}
-	if _, _, err := conn.textConn.ReadResponse(250); err != nil {
-		return err
+
+	var statuses []RecipientStatus
+	if c.proto == protoLMTP {
+		statuses, err = readLMTPStatuses(conn, recipients)
+		if err != nil {
+			return statuses, err
+		}
+	} else if _, _, err := conn.textConn.ReadResponse(250); err != nil {
+		return nil, err
 	}
 
 	if _, _, err := conn.execCmd(221, "QUIT"); err != nil {
-		return err
+		return statuses, err
 	}
-	return nil
+	return statuses, nil
 }
 
 // Request represents an mail request.
@@ -245,7 +416,14 @@ type Request struct {
 	Body      io.ReadCloser
 	StartTLS  bool
 	TLSConfig *tls.Config
+	Auth      Auth
+	DKIM      *DKIMSigner
 	ctx       context.Context
+
+	body         *bodyPart
+	alternatives []*bodyPart
+	attachments  []*attachmentPart
+	embeds       []*attachmentPart
 }
 
 // NewRequest returns new Request.
@@ -280,16 +458,34 @@ func (r *Request) Write(w io.Writer) error {
 			return err
 		}
 	}
-	if err := writeHeader(w, "Subject", r.Subject); err != nil {
+	if err := writeHeader(w, "Subject", encodeHeaderValue(r.Subject)); err != nil {
 		return err
 	}
-	if err := r.Header.WriteSubset(w, defaultExcludeHeaders); err != nil {
+
+	if !r.hasMIMEParts() {
+		if err := r.Header.WriteSubset(w, defaultExcludeHeaders); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, r.Body)
 		return err
 	}
-	if _, err := io.Copy(w, r.Body); err != nil {
+
+	node := r.mimeNode()
+	if err := writeHeader(w, "MIME-Version", "1.0"); err != nil {
 		return err
 	}
-	return nil
+	if err := writeHeader(w, "Content-Type", node.contentType); err != nil {
+		return err
+	}
+	if node.transferEncoding != "" {
+		if err := writeHeader(w, "Content-Transfer-Encoding", node.transferEncoding); err != nil {
+			return err
+		}
+	}
+	if err := r.Header.WriteSubset(w, defaultExcludeHeaders); err != nil {
+		return err
+	}
+	return node.write(w)
 }
 
 // Header represents the key-value pairs in an SMTP header.